@@ -0,0 +1,94 @@
+package splittoken_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/iterate/splittoken"
+)
+
+type mapKeyring map[string][]byte
+
+func (m mapKeyring) Get(id string) ([]byte, bool) {
+	k, ok := m[id]
+	return k, ok
+}
+
+func TestNewAuthenticated(t *testing.T) {
+	id := uuid.New()
+	bs := make([]byte, 24)
+	if _, err := rand.Read(bs); err != nil {
+		t.Fatalf("reading bytes: %v", err)
+	}
+	keyring := mapKeyring{"k1": []byte("super-secret-signing-key")}
+
+	tk, err := splittoken.NewAuthenticated("test", id, bs, "k1", keyring["k1"])
+	if err != nil {
+		t.Fatalf("NewAuthenticated() returned err %v", err)
+	}
+	if err := splittoken.VerifyAuthenticated(tk, keyring); err != nil {
+		t.Errorf("got invalid token: %v", err)
+	}
+}
+
+func TestVerifyAuthenticated(t *testing.T) {
+	id := uuid.New()
+	secret := []byte("autogenerated secret")
+	keyring := mapKeyring{"k1": []byte("signing-key-one"), "k2": []byte("signing-key-two")}
+
+	tk, err := splittoken.NewAuthenticated("test", id, secret, "k1", keyring["k1"])
+	if err != nil {
+		t.Fatalf("NewAuthenticated() returned err %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		tk      splittoken.Token
+		kr      splittoken.Keyring
+		wantErr error
+	}{
+		{"Valid", tk, keyring, nil},
+		{"Unknown key", tk, mapKeyring{"k2": keyring["k2"]}, splittoken.ErrUnknownKey},
+		{"Wrong key", tk, mapKeyring{"k1": keyring["k2"]}, splittoken.ErrInvalidChecksum},
+		{"Invalid syntax", "test.not-a-token", keyring, splittoken.ErrInvalidSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := splittoken.VerifyAuthenticated(tt.tk, tt.kr); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyAuthenticated() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticatedKeyRotation(t *testing.T) {
+	id := uuid.New()
+	secret := []byte("autogenerated secret")
+
+	keyring := mapKeyring{"k1": []byte("old-key")}
+	tk, err := splittoken.NewAuthenticated("test", id, secret, "k1", keyring["k1"])
+	if err != nil {
+		t.Fatalf("NewAuthenticated() returned err %v", err)
+	}
+
+	// Rotating in a new key without dropping the old one must not
+	// invalidate tokens issued under "k1".
+	keyring["k2"] = []byte("new-key")
+	if err := splittoken.VerifyAuthenticated(tk, keyring); err != nil {
+		t.Errorf("got invalid token after rotation: %v", err)
+	}
+
+	newTk, err := splittoken.NewAuthenticated("test", id, secret, "k2", keyring["k2"])
+	if err != nil {
+		t.Fatalf("NewAuthenticated() returned err %v", err)
+	}
+	if bytes.Equal([]byte(tk), []byte(newTk)) {
+		t.Errorf("tokens signed under different key ids should differ")
+	}
+	if err := splittoken.VerifyAuthenticated(newTk, keyring); err != nil {
+		t.Errorf("got invalid token for k2: %v", err)
+	}
+}
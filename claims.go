@@ -0,0 +1,237 @@
+package splittoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/google/uuid"
+)
+
+// versionClaims marks a token produced by NewWithClaims. Like
+// versionAuthenticated, it is deliberately never registered with
+// RegisterVersion: a claims-bearing token's body starts with a keyID and a
+// variable-length claims blob rather than the plain secret+tag layout a
+// Codec can describe, so it's parsed by parseClaims instead of the generic
+// parse(). parseClaims fails fast on any other leading byte, so a v2 token
+// is never misread as claims-bearing. A v1 token has no version byte, so
+// one whose serial starts with this byte passes that check, and parse()
+// reads a claims-bearing token as a legacy v1 token; Token.Claims therefore
+// checks the v1 checksum before trying parseClaims.
+const versionClaims uint8 = 5
+
+// ErrTokenExpired is returned by VerifyAt when a token's embedded Claims
+// has a NotAfter in the past.
+var ErrTokenExpired = errors.New("token expired")
+
+// Claims is an optional structured payload embedded between a token's
+// serial and its secret, letting a caller encode an expiry and scope list
+// that a service can check without a database lookup.
+type Claims struct {
+	// NotAfter is the time after which the token must be rejected. The
+	// zero value means the token never expires.
+	NotAfter time.Time
+	// Scopes is a compact list of the actions the token is good for.
+	Scopes []string
+	// Aud is the intended audience of the token, e.g. a service name.
+	Aud string
+}
+
+// claimsWire is Claims' CBOR wire representation: NotAfter is stored as
+// Unix seconds, matching the compactness the rest of the token format
+// aims for.
+type claimsWire struct {
+	Exp    uint64   `cbor:"exp,omitempty"`
+	Scopes []string `cbor:"scopes,omitempty"`
+	Aud    string   `cbor:"aud,omitempty"`
+}
+
+func marshalClaims(c Claims) ([]byte, error) {
+	w := claimsWire{Scopes: c.Scopes, Aud: c.Aud}
+	if !c.NotAfter.IsZero() {
+		w.Exp = uint64(c.NotAfter.Unix())
+	}
+	return cbor.Marshal(w)
+}
+
+func unmarshalClaims(bs []byte) (Claims, error) {
+	if len(bs) == 0 {
+		return Claims{}, nil
+	}
+	var w claimsWire
+	if err := cbor.Unmarshal(bs, &w); err != nil {
+		return Claims{}, ErrInvalidSyntax
+	}
+	c := Claims{Scopes: w.Scopes, Aud: w.Aud}
+	if w.Exp != 0 {
+		c.NotAfter = time.Unix(int64(w.Exp), 0)
+	}
+	return c, nil
+}
+
+// claimsParts is the decoded form of a claims-bearing token, plus the tag
+// embedded in it, prior to MAC verification.
+type claimsParts struct {
+	keyID  string
+	usage  string
+	serial uuid.UUID
+	claims []byte
+	secret []byte
+	tag    []byte
+}
+
+// NewWithClaims constructs a token like NewAuthenticated, but with an
+// additional Claims payload between the serial and the secret. The tag is
+// a truncated HMAC-SHA256 over usage, serial, the encoded claims, secret
+// and keyID, so claims cannot be tampered with independently of the rest
+// of the token; verifying it therefore needs a Keyring, exactly like
+// VerifyAuthenticated.
+//
+// The wire layout is:
+//
+//	version(1) || keyIDLen(1) || keyID || serial(16) || claimsLen(varint) || claims || secret || tag(16)
+func NewWithClaims(usage string, serial uuid.UUID, secret []byte, claims Claims, keyID string, key []byte) (Token, error) {
+	if len(usage) < 1 {
+		return "", ErrInvalidSyntax
+	}
+	if len(secret) < 1 {
+		return "", ErrInvalidSyntax
+	}
+	if len(keyID) < 1 || len(keyID) > 255 {
+		return "", ErrInvalidSyntax
+	}
+
+	encodedClaims, err := marshalClaims(claims)
+	if err != nil {
+		return "", err
+	}
+
+	p := claimsParts{keyID: keyID, usage: usage, serial: serial, claims: encodedClaims, secret: secret}
+	p.tag = claimsTag(p, key)
+	return encodeClaims(p)
+}
+
+// Claims returns the Claims embedded in t, decoding whichever wire format
+// produced it. Tokens with no Claims payload - including every v1
+// CRC32/UUID token issued before this feature existed - parse successfully
+// and return a zero Claims, so existing callers of New/Verify are
+// unaffected.
+func (t Token) Claims() (Claims, error) {
+	if Verify(t) == nil {
+		return Claims{}, nil
+	}
+	if p, err := parseClaims(t); err == nil {
+		return unmarshalClaims(p.claims)
+	}
+	if _, err := parse(t); err == nil {
+		return Claims{}, nil
+	}
+	return Claims{}, ErrInvalidSyntax
+}
+
+// VerifyAt checks t's HMAC tag against the key named by its keyID, as
+// resolved by kr, and rejects it with ErrTokenExpired if its Claims has a
+// NotAfter at or before now.
+func VerifyAt(t Token, now time.Time, kr Keyring) error {
+	p, err := parseClaims(t)
+	if err != nil {
+		return err
+	}
+	key, ok := kr.Get(p.keyID)
+	if !ok {
+		return ErrUnknownKey
+	}
+	want := claimsTag(p, key)
+	if subtle.ConstantTimeCompare(want, p.tag) != 1 {
+		return ErrInvalidChecksum
+	}
+
+	claims, err := unmarshalClaims(p.claims)
+	if err != nil {
+		return err
+	}
+	if !claims.NotAfter.IsZero() && !now.Before(claims.NotAfter) {
+		return ErrTokenExpired
+	}
+	return nil
+}
+
+func claimsTag(p claimsParts, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(p.usage))
+	mac.Write(p.serial[:])
+	mac.Write(p.claims)
+	mac.Write(p.secret)
+	mac.Write([]byte(p.keyID))
+	return mac.Sum(nil)[:macSize]
+}
+
+func encodeClaims(p claimsParts) (Token, error) {
+	idLen := len(p.keyID)
+
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, uint64(len(p.claims)))
+	lenBuf = lenBuf[:n]
+
+	bl := 1 + 1 + idLen + 16 + len(lenBuf) + len(p.claims) + len(p.secret) + macSize
+	bs := make([]byte, 0, bl)
+	bs = append(bs, versionClaims)
+	bs = append(bs, byte(idLen))
+	bs = append(bs, p.keyID...)
+	bs = append(bs, p.serial[:]...)
+	bs = append(bs, lenBuf...)
+	bs = append(bs, p.claims...)
+	bs = append(bs, p.secret...)
+	bs = append(bs, p.tag...)
+
+	return Token(p.usage + "_" + enc.Encode(bs)), nil
+}
+
+func parseClaims(t Token) (claimsParts, error) {
+	var res claimsParts
+	usage, payload, ok := cutUsage(string(t))
+	if !ok {
+		return res, ErrInvalidSyntax
+	}
+	res.usage = usage
+
+	bs, err := enc.Decode(payload)
+	if err != nil || len(bs) < 1 || bs[0] != versionClaims {
+		return res, ErrInvalidSyntax
+	}
+	body := bs[1:]
+
+	if len(body) < 1 {
+		return res, ErrInvalidSyntax
+	}
+	idLen := int(body[0])
+	if len(body) < 1+idLen+16 {
+		return res, ErrInvalidSyntax
+	}
+	res.keyID = string(body[1 : 1+idLen])
+	copy(res.serial[:], body[1+idLen:1+idLen+16])
+
+	rest := body[1+idLen+16:]
+	claimsLen, n := binary.Uvarint(rest)
+	if n <= 0 {
+		return res, ErrInvalidSyntax
+	}
+	rest = rest[n:]
+	if uint64(len(rest)) < claimsLen+macSize+1 {
+		return res, ErrInvalidSyntax
+	}
+
+	res.claims = rest[:claimsLen]
+	secretAndTag := rest[claimsLen:]
+	secretEnd := len(secretAndTag) - macSize
+	if secretEnd < 1 {
+		return res, ErrInvalidSyntax
+	}
+	res.secret = secretAndTag[:secretEnd]
+	res.tag = secretAndTag[secretEnd:]
+	return res, nil
+}
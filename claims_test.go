@@ -0,0 +1,78 @@
+package splittoken_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iterate/splittoken"
+)
+
+func TestNewWithClaims(t *testing.T) {
+	id := uuid.New()
+	secret := []byte("autogenerated secret")
+	key := []byte("signing-key")
+	claims := splittoken.Claims{
+		NotAfter: time.Now().Add(time.Hour),
+		Scopes:   []string{"read", "write"},
+		Aud:      "api.example.com",
+	}
+
+	tk, err := splittoken.NewWithClaims("test", id, secret, claims, "k1", key)
+	if err != nil {
+		t.Fatalf("NewWithClaims() returned err %v", err)
+	}
+
+	got, err := tk.Claims()
+	if err != nil {
+		t.Fatalf("Claims() returned err %v", err)
+	}
+	if got.Aud != claims.Aud {
+		t.Errorf("Claims().Aud = %q; want %q", got.Aud, claims.Aud)
+	}
+	if len(got.Scopes) != 2 || got.Scopes[0] != "read" || got.Scopes[1] != "write" {
+		t.Errorf("Claims().Scopes = %v; want %v", got.Scopes, claims.Scopes)
+	}
+	if !got.NotAfter.Equal(claims.NotAfter.Truncate(time.Second)) {
+		t.Errorf("Claims().NotAfter = %v; want %v", got.NotAfter, claims.NotAfter)
+	}
+}
+
+func TestVerifyAt(t *testing.T) {
+	id := uuid.New()
+	secret := []byte("autogenerated secret")
+	keyring := mapKeyring{"k1": []byte("signing-key")}
+
+	tk, err := splittoken.NewWithClaims("test", id, secret, splittoken.Claims{
+		NotAfter: time.Now().Add(time.Hour),
+	}, "k1", keyring["k1"])
+	if err != nil {
+		t.Fatalf("NewWithClaims() returned err %v", err)
+	}
+
+	if err := splittoken.VerifyAt(tk, time.Now(), keyring); err != nil {
+		t.Errorf("VerifyAt() now error = %v, want nil", err)
+	}
+	if err := splittoken.VerifyAt(tk, time.Now().Add(2*time.Hour), keyring); !errors.Is(err, splittoken.ErrTokenExpired) {
+		t.Errorf("VerifyAt() after expiry error = %v, want %v", err, splittoken.ErrTokenExpired)
+	}
+}
+
+func TestClaimsOnLegacyToken(t *testing.T) {
+	id := uuid.New()
+	secret := []byte("autogenerated secret")
+
+	tk, err := splittoken.New("test", id, secret)
+	if err != nil {
+		t.Fatalf("New() returned err %v", err)
+	}
+
+	got, err := tk.Claims()
+	if err != nil {
+		t.Fatalf("Claims() on a v1 token returned err %v", err)
+	}
+	if !got.NotAfter.IsZero() || len(got.Scopes) != 0 || got.Aud != "" {
+		t.Errorf("Claims() on a v1 token = %+v; want the zero value", got)
+	}
+}
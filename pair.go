@@ -0,0 +1,60 @@
+package splittoken
+
+import (
+	"crypto/rand"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// pairSecretBytes is the length of the randomly generated secret for each
+// half of a Pair.
+const pairSecretBytes = 24
+
+// Pair is a linked access/refresh token pair, as returned by NewPair.
+type Pair struct {
+	Access  Token
+	Refresh Token
+
+	AccessExpiresAt  time.Time
+	RefreshExpiresAt time.Time
+}
+
+// NewPair mints a linked access/refresh Pair: both tokens share a serial
+// UUID, so a single database lookup by serial finds a record good for
+// either of them, but they carry independent secrets and are tagged with
+// distinct usage suffixes - "_at" for the access token, "_rt" for the
+// refresh token - so one can never be mistaken for the other.
+//
+// NewPair only mints the tokens; it doesn't persist anything. Pair it with
+// store.IssuePair and store.Rotate for a short-lived-access,
+// long-lived-refresh workflow with server-side revocation.
+func NewPair(usage string, accessTTL, refreshTTL time.Duration) (Pair, error) {
+	serial := uuid.Must(uuid.NewRandom())
+
+	accessSecret := make([]byte, pairSecretBytes)
+	if _, err := rand.Read(accessSecret); err != nil {
+		return Pair{}, err
+	}
+	refreshSecret := make([]byte, pairSecretBytes)
+	if _, err := rand.Read(refreshSecret); err != nil {
+		return Pair{}, err
+	}
+
+	access, err := New(usage+"_at", serial, accessSecret)
+	if err != nil {
+		return Pair{}, err
+	}
+	refresh, err := New(usage+"_rt", serial, refreshSecret)
+	if err != nil {
+		return Pair{}, err
+	}
+
+	now := time.Now()
+	return Pair{
+		Access:           access,
+		Refresh:          refresh,
+		AccessExpiresAt:  now.Add(accessTTL),
+		RefreshExpiresAt: now.Add(refreshTTL),
+	}, nil
+}
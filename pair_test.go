@@ -0,0 +1,34 @@
+package splittoken_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iterate/splittoken"
+)
+
+func TestNewPair(t *testing.T) {
+	pair, err := splittoken.NewPair("session", 5*time.Minute, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("NewPair() returned err %v", err)
+	}
+
+	if pair.Access.Serial() != pair.Refresh.Serial() {
+		t.Errorf("Access.Serial() = %s; Refresh.Serial() = %s; want equal", pair.Access.Serial(), pair.Refresh.Serial())
+	}
+	if pair.Access.Usage() != "session_at" {
+		t.Errorf("Access.Usage() = %q; want %q", pair.Access.Usage(), "session_at")
+	}
+	if pair.Refresh.Usage() != "session_rt" {
+		t.Errorf("Refresh.Usage() = %q; want %q", pair.Refresh.Usage(), "session_rt")
+	}
+	if pair.AccessExpiresAt.After(pair.RefreshExpiresAt) {
+		t.Errorf("AccessExpiresAt %v is after RefreshExpiresAt %v", pair.AccessExpiresAt, pair.RefreshExpiresAt)
+	}
+	if err := splittoken.Verify(pair.Access); err != nil {
+		t.Errorf("Verify(Access) returned err %v", err)
+	}
+	if err := splittoken.Verify(pair.Refresh); err != nil {
+		t.Errorf("Verify(Refresh) returned err %v", err)
+	}
+}
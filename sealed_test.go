@@ -0,0 +1,67 @@
+package splittoken_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/iterate/splittoken"
+)
+
+func TestNewEncrypted(t *testing.T) {
+	pub, priv, err := splittoken.GenerateRecipient()
+	if err != nil {
+		t.Fatalf("GenerateRecipient() returned err %v", err)
+	}
+
+	secret := []byte("autogenerated secret")
+	tk, err := splittoken.NewEncrypted("test", uuid.New(), secret, pub)
+	if err != nil {
+		t.Fatalf("NewEncrypted() returned err %v", err)
+	}
+
+	got, err := tk.Open(priv)
+	if err != nil {
+		t.Fatalf("Open() returned err %v", err)
+	}
+	if !bytes.Equal(got, secret) {
+		t.Errorf("Open() = %x; want %x", got, secret)
+	}
+}
+
+func TestOpenWrongKey(t *testing.T) {
+	pub, _, err := splittoken.GenerateRecipient()
+	if err != nil {
+		t.Fatalf("GenerateRecipient() returned err %v", err)
+	}
+	_, otherPriv, err := splittoken.GenerateRecipient()
+	if err != nil {
+		t.Fatalf("GenerateRecipient() returned err %v", err)
+	}
+
+	tk, err := splittoken.NewEncrypted("test", uuid.New(), []byte("a secret"), pub)
+	if err != nil {
+		t.Fatalf("NewEncrypted() returned err %v", err)
+	}
+
+	if _, err := tk.Open(otherPriv); !errors.Is(err, splittoken.ErrInvalidChecksum) {
+		t.Errorf("Open() with wrong key error = %v, want %v", err, splittoken.ErrInvalidChecksum)
+	}
+}
+
+func TestOpenNotSealed(t *testing.T) {
+	_, priv, err := splittoken.GenerateRecipient()
+	if err != nil {
+		t.Fatalf("GenerateRecipient() returned err %v", err)
+	}
+
+	tk, err := splittoken.New("test", uuid.New(), []byte("a secret"))
+	if err != nil {
+		t.Fatalf("New() returned err %v", err)
+	}
+
+	if _, err := tk.Open(priv); !errors.Is(err, splittoken.ErrNotSealed) {
+		t.Errorf("Open() on an unsealed token error = %v, want %v", err, splittoken.ErrNotSealed)
+	}
+}
@@ -0,0 +1,139 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/iterate/splittoken"
+)
+
+var _ TokenStore = (*Memory)(nil)
+
+// Memory is an in-memory TokenStore, useful for tests and single-process
+// services that don't need tokens to survive a restart.
+type Memory struct {
+	SecretBytes uint
+	HashParams  HashParams
+
+	mu      sync.Mutex
+	records map[uuid.UUID]Record
+}
+
+// NewMemory constructs an empty Memory store that issues secretBytes-byte
+// secrets and hashes them with DefaultHashParams.
+func NewMemory(secretBytes uint) *Memory {
+	return &Memory{SecretBytes: secretBytes, HashParams: DefaultHashParams}
+}
+
+func (m *Memory) Issue(ctx context.Context, usage string, ttl time.Duration) (splittoken.Token, Record, error) {
+	tk, err := splittoken.Generate(usage, m.SecretBytes)
+	if err != nil {
+		return "", Record{}, err
+	}
+
+	salt := make([]byte, m.HashParams.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", Record{}, err
+	}
+
+	now := time.Now()
+	rec := Record{
+		Serial:     tk.Serial(),
+		Usage:      usage,
+		SecretHash: hashSecret(tk.Secret(), m.HashParams, salt),
+		IssuedAt:   now,
+		ExpiresAt:  now.Add(ttl),
+	}
+
+	m.mu.Lock()
+	if m.records == nil {
+		m.records = map[uuid.UUID]Record{}
+	}
+	m.records[rec.Serial] = rec
+	m.mu.Unlock()
+
+	return tk, rec, nil
+}
+
+func (m *Memory) Lookup(ctx context.Context, t splittoken.Token) (Record, error) {
+	if err := splittoken.Verify(t); err != nil {
+		return Record{}, err
+	}
+
+	m.mu.Lock()
+	rec, ok := m.records[t.Serial()]
+	m.mu.Unlock()
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	if !compareSecret(t.Secret(), rec.SecretHash) {
+		return Record{}, ErrSecretMismatch
+	}
+	if time.Now().After(rec.ExpiresAt) {
+		return Record{}, ErrExpired
+	}
+	return rec, nil
+}
+
+func (m *Memory) Put(ctx context.Context, rec Record) error {
+	m.mu.Lock()
+	if m.records == nil {
+		m.records = map[uuid.UUID]Record{}
+	}
+	m.records[rec.Serial] = rec
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Memory) Get(ctx context.Context, serial uuid.UUID) (Record, error) {
+	m.mu.Lock()
+	rec, ok := m.records[serial]
+	m.mu.Unlock()
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	return rec, nil
+}
+
+func (m *Memory) CompareAndSwap(ctx context.Context, rec Record, prevMetadata []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.records[rec.Serial]
+	if !ok || !bytes.Equal(cur.Metadata, prevMetadata) {
+		return false, nil
+	}
+	if m.records == nil {
+		m.records = map[uuid.UUID]Record{}
+	}
+	m.records[rec.Serial] = rec
+	return true, nil
+}
+
+func (m *Memory) Revoke(ctx context.Context, serial uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.records[serial]; !ok {
+		return ErrNotFound
+	}
+	delete(m.records, serial)
+	return nil
+}
+
+func (m *Memory) Sweep(ctx context.Context) (int, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for serial, rec := range m.records {
+		if now.After(rec.ExpiresAt) {
+			delete(m.records, serial)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,85 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iterate/splittoken/store"
+)
+
+func TestMemoryIssueAndLookup(t *testing.T) {
+	ms := store.NewMemory(24)
+	ctx := context.Background()
+
+	tk, rec, err := ms.Issue(ctx, "test", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+	if rec.Usage != "test" {
+		t.Errorf("rec.Usage = %q; want %q", rec.Usage, "test")
+	}
+
+	got, err := ms.Lookup(ctx, tk)
+	if err != nil {
+		t.Fatalf("Lookup() returned err %v", err)
+	}
+	if got.Serial != rec.Serial {
+		t.Errorf("Lookup().Serial = %s; want %s", got.Serial, rec.Serial)
+	}
+}
+
+func TestMemoryLookupExpired(t *testing.T) {
+	ms := store.NewMemory(24)
+	ctx := context.Background()
+
+	tk, _, err := ms.Issue(ctx, "test", -time.Second)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	if _, err := ms.Lookup(ctx, tk); !errors.Is(err, store.ErrExpired) {
+		t.Errorf("Lookup() error = %v, want %v", err, store.ErrExpired)
+	}
+}
+
+func TestMemoryRevoke(t *testing.T) {
+	ms := store.NewMemory(24)
+	ctx := context.Background()
+
+	tk, rec, err := ms.Issue(ctx, "test", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	if err := ms.Revoke(ctx, rec.Serial); err != nil {
+		t.Fatalf("Revoke() returned err %v", err)
+	}
+	if _, err := ms.Lookup(ctx, tk); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Lookup() after revoke error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := ms.Revoke(ctx, rec.Serial); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second Revoke() error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	ms := store.NewMemory(24)
+	ctx := context.Background()
+
+	if _, _, err := ms.Issue(ctx, "test", -time.Second); err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+	if _, _, err := ms.Issue(ctx, "test", time.Hour); err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	n, err := ms.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep() returned err %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Sweep() = %d; want 1", n)
+	}
+}
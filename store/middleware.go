@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/iterate/splittoken"
+)
+
+type contextKey int
+
+const recordContextKey contextKey = 0
+
+// Middleware resolves a bearer split token from the headerName request
+// header (e.g. "Authorization", with a "Bearer " prefix) against store,
+// and injects the resulting Record into the request context so downstream
+// handlers can retrieve it with RecordFromContext. Requests with a missing,
+// malformed, or unresolvable token are rejected with 401 Unauthorized
+// before reaching next.
+func Middleware(store TokenStore, headerName string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tk, ok := bearerToken(r, headerName)
+			if !ok {
+				http.Error(w, "missing or malformed bearer token", http.StatusUnauthorized)
+				return
+			}
+
+			rec, err := store.Lookup(r.Context(), splittoken.Token(tk))
+			if err != nil {
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), recordContextKey, rec)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RecordFromContext returns the Record placed in ctx by Middleware, and
+// whether one was present.
+func RecordFromContext(ctx context.Context) (Record, bool) {
+	rec, ok := ctx.Value(recordContextKey).(Record)
+	return rec, ok
+}
+
+func bearerToken(r *http.Request, headerName string) (string, bool) {
+	h := r.Header.Get(headerName)
+	const prefix = "Bearer "
+	if !strings.HasPrefix(h, prefix) {
+		return "", false
+	}
+	tk := strings.TrimPrefix(h, prefix)
+	if tk == "" {
+		return "", false
+	}
+	return tk, true
+}
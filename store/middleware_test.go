@@ -0,0 +1,60 @@
+package store_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iterate/splittoken/store"
+)
+
+func TestMiddleware(t *testing.T) {
+	ms := store.NewMemory(24)
+	tk, rec, err := ms.Issue(httptest.NewRequest("GET", "/", nil).Context(), "test", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	var gotRec store.Record
+	var gotOK bool
+	h := store.Middleware(ms, "Authorization")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRec, gotOK = store.RecordFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	t.Run("Valid", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+string(tk))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("status = %d; want %d", rr.Code, http.StatusOK)
+		}
+		if !gotOK || gotRec.Serial != rec.Serial {
+			t.Errorf("RecordFromContext() = %+v, %v; want record for serial %s", gotRec, gotOK, rec.Serial)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d; want %d", rr.Code, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("Garbage", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer not-a-real-token")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d; want %d", rr.Code, http.StatusUnauthorized)
+		}
+	})
+}
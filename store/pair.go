@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/iterate/splittoken"
+)
+
+// ErrReplay is returned by Rotate when refresh names a pair that was
+// already rotated once before. A legitimate client always moves on to the
+// new pair's refresh token after a rotation, so a second presentation of
+// an old one means it was copied by an attacker; Rotate responds by
+// revoking that record and every generation descended from it, rather than
+// just rejecting the request.
+var ErrReplay = errors.New("splittoken/store: refresh token reuse detected")
+
+// pairMeta is encoded into a pair's Record.Metadata. A pair's access token
+// is validated statelessly with splittoken.Verify and never touches the
+// store, so the Record - keyed by the tokens' shared serial - only needs
+// to carry the refresh token's hash plus what Rotate needs to mint the
+// next generation with the same lifetimes.
+type pairMeta struct {
+	Rotated    bool          `json:"rotated"`
+	AccessTTL  time.Duration `json:"access_ttl"`
+	RefreshTTL time.Duration `json:"refresh_ttl"`
+	// Family is the serial of the pair IssuePair originally minted; it
+	// is carried forward unchanged across every generation Rotate
+	// produces. revokeFamily does not read it; it finds descendants by
+	// following Next.
+	Family uuid.UUID `json:"family"`
+	// Next is the serial of the record the pair was rotated into, so
+	// Rotate can walk forward from a stale record on replay. It is the
+	// zero UUID until this record is rotated.
+	Next uuid.UUID `json:"next,omitempty"`
+}
+
+// IssuePair mints a splittoken.Pair via splittoken.NewPair and persists a
+// single Record for it, keyed by the pair's shared serial, so Rotate can
+// later find and replace it.
+func IssuePair(ctx context.Context, ts TokenStore, usage string, accessTTL, refreshTTL time.Duration) (splittoken.Pair, error) {
+	pair, err := splittoken.NewPair(usage, accessTTL, refreshTTL)
+	if err != nil {
+		return splittoken.Pair{}, err
+	}
+	family := pair.Refresh.Serial()
+	if err := putPairRecord(ctx, ts, pair, accessTTL, refreshTTL, family); err != nil {
+		return splittoken.Pair{}, err
+	}
+	return pair, nil
+}
+
+// Rotate replaces refresh's pair with a newly minted one, reusing its
+// original access/refresh TTLs. A second Rotate call with the same refresh
+// token - which a legitimate client never makes, since it would have moved
+// on to the new pair - is treated as reuse: Rotate revokes every generation
+// descended from it, all the way to the currently active pair, and returns
+// ErrReplay instead of a new Pair.
+//
+// The record marking a refresh token rotated is written with
+// CompareAndSwap, conditioned on the Metadata Rotate originally read, so
+// two concurrent Rotate calls racing on the same still-valid refresh token
+// can't both win: whichever call loses the race has its new pair's record
+// revoked and gets ErrReplay, exactly as if it had presented an
+// already-rotated token.
+func Rotate(ctx context.Context, ts TokenStore, refresh splittoken.Token) (splittoken.Pair, error) {
+	rec, err := ts.Lookup(ctx, refresh)
+	if err != nil {
+		return splittoken.Pair{}, err
+	}
+
+	var meta pairMeta
+	if err := json.Unmarshal(rec.Metadata, &meta); err != nil {
+		return splittoken.Pair{}, err
+	}
+	if meta.Rotated {
+		revokeFamily(ctx, ts, rec)
+		return splittoken.Pair{}, ErrReplay
+	}
+
+	pair, err := splittoken.NewPair(rec.Usage, meta.AccessTTL, meta.RefreshTTL)
+	if err != nil {
+		return splittoken.Pair{}, err
+	}
+	if err := putPairRecord(ctx, ts, pair, meta.AccessTTL, meta.RefreshTTL, meta.Family); err != nil {
+		return splittoken.Pair{}, err
+	}
+
+	prevMetadata := rec.Metadata
+	meta.Rotated = true
+	meta.Next = pair.Refresh.Serial()
+	rec.Metadata, err = json.Marshal(meta)
+	if err != nil {
+		return splittoken.Pair{}, err
+	}
+	swapped, err := ts.CompareAndSwap(ctx, rec, prevMetadata)
+	if err != nil {
+		return splittoken.Pair{}, err
+	}
+	if !swapped {
+		// Another Rotate call already marked rec rotated between our
+		// Lookup and this swap. The pair we minted above was never
+		// handed out, so revoke it rather than leaving it as an
+		// orphaned, unreferenced record, then revoke the family
+		// starting from the winner's actual record - not the one we
+		// built locally, which points at the orphan we just revoked.
+		_ = ts.Revoke(ctx, pair.Refresh.Serial())
+		if winner, err := ts.Get(ctx, rec.Serial); err == nil {
+			revokeFamily(ctx, ts, winner)
+		}
+		return splittoken.Pair{}, ErrReplay
+	}
+
+	return pair, nil
+}
+
+// revokeFamily revokes rec and, by following each generation's Next serial,
+// every record descended from it - so that replaying any one stale refresh
+// token in a family invalidates the family's current, still-active pair
+// too, not just the generation that was replayed. It stops after revoking
+// a record with no Next yet, which is the current generation, or when the
+// Next record can't be loaded, for instance because it's already been
+// revoked.
+func revokeFamily(ctx context.Context, ts TokenStore, rec Record) {
+	seen := map[uuid.UUID]bool{}
+	for {
+		if seen[rec.Serial] {
+			return
+		}
+		seen[rec.Serial] = true
+		_ = ts.Revoke(ctx, rec.Serial)
+
+		var meta pairMeta
+		if err := json.Unmarshal(rec.Metadata, &meta); err != nil || meta.Next == uuid.Nil {
+			return
+		}
+		next, err := ts.Get(ctx, meta.Next)
+		if err != nil {
+			return
+		}
+		rec = next
+	}
+}
+
+func putPairRecord(ctx context.Context, ts TokenStore, pair splittoken.Pair, accessTTL, refreshTTL time.Duration, family uuid.UUID) error {
+	salt := make([]byte, DefaultHashParams.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(pairMeta{AccessTTL: accessTTL, RefreshTTL: refreshTTL, Family: family})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return ts.Put(ctx, Record{
+		Serial:     pair.Refresh.Serial(),
+		Usage:      strings.TrimSuffix(pair.Refresh.Usage(), "_rt"),
+		SecretHash: hashSecret(pair.Refresh.Secret(), DefaultHashParams, salt),
+		IssuedAt:   now,
+		ExpiresAt:  pair.RefreshExpiresAt,
+		Metadata:   meta,
+	})
+}
@@ -0,0 +1,131 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iterate/splittoken/store"
+)
+
+func TestIssuePairAndRotate(t *testing.T) {
+	ms := store.NewMemory(24)
+	ctx := context.Background()
+
+	pair, err := store.IssuePair(ctx, ms, "session", 5*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("IssuePair() returned err %v", err)
+	}
+
+	rotated, err := store.Rotate(ctx, ms, pair.Refresh)
+	if err != nil {
+		t.Fatalf("Rotate() returned err %v", err)
+	}
+	if rotated.Refresh == pair.Refresh {
+		t.Errorf("Rotate() returned the same refresh token")
+	}
+}
+
+func TestRotateDetectsReplay(t *testing.T) {
+	ms := store.NewMemory(24)
+	ctx := context.Background()
+
+	pair, err := store.IssuePair(ctx, ms, "session", 5*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("IssuePair() returned err %v", err)
+	}
+
+	if _, err := store.Rotate(ctx, ms, pair.Refresh); err != nil {
+		t.Fatalf("first Rotate() returned err %v", err)
+	}
+
+	// Replaying the same (now stale) refresh token must be treated as
+	// reuse and revoke the family.
+	if _, err := store.Rotate(ctx, ms, pair.Refresh); !errors.Is(err, store.ErrReplay) {
+		t.Errorf("second Rotate() error = %v, want %v", err, store.ErrReplay)
+	}
+
+	if _, err := store.Rotate(ctx, ms, pair.Refresh); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("third Rotate() error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func TestRotateReplayRevokesWholeFamily(t *testing.T) {
+	ms := store.NewMemory(24)
+	ctx := context.Background()
+
+	gen1, err := store.IssuePair(ctx, ms, "session", 5*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("IssuePair() returned err %v", err)
+	}
+	gen2, err := store.Rotate(ctx, ms, gen1.Refresh)
+	if err != nil {
+		t.Fatalf("first Rotate() returned err %v", err)
+	}
+	gen3, err := store.Rotate(ctx, ms, gen2.Refresh)
+	if err != nil {
+		t.Fatalf("second Rotate() returned err %v", err)
+	}
+
+	// Replaying gen1's refresh token - two generations stale - must
+	// revoke not just gen1 but every descendant, including the
+	// currently active gen3 pair.
+	if _, err := store.Rotate(ctx, ms, gen1.Refresh); !errors.Is(err, store.ErrReplay) {
+		t.Fatalf("Rotate() on stale gen1 refresh error = %v, want %v", err, store.ErrReplay)
+	}
+
+	if _, err := ms.Lookup(ctx, gen3.Refresh); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("gen3 refresh Lookup() after family replay error = %v, want %v", err, store.ErrNotFound)
+	}
+	if _, err := store.Rotate(ctx, ms, gen3.Refresh); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Rotate() on gen3 refresh after family replay error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+// TestRotateConcurrentRaceHasOneWinner exercises two goroutines racing
+// Rotate on the same still-valid refresh token. Without a CAS guarding the
+// Rotated flag, both could win, defeating replay detection; here exactly
+// one must succeed and the other must observe ErrReplay.
+func TestRotateConcurrentRaceHasOneWinner(t *testing.T) {
+	ms := store.NewMemory(24)
+	ctx := context.Background()
+
+	pair, err := store.IssuePair(ctx, ms, "session", 5*time.Minute, time.Hour)
+	if err != nil {
+		t.Fatalf("IssuePair() returned err %v", err)
+	}
+
+	const attempts = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	successes := 0
+	replays := 0
+
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Rotate(ctx, ms, pair.Refresh)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				successes++
+			case errors.Is(err, store.ErrReplay):
+				replays++
+			default:
+				t.Errorf("Rotate() returned unexpected err %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if successes != 1 {
+		t.Errorf("successes = %d; want exactly 1", successes)
+	}
+	if replays != attempts-1 {
+		t.Errorf("replays = %d; want %d", replays, attempts-1)
+	}
+}
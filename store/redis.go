@@ -0,0 +1,193 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/iterate/splittoken"
+)
+
+var _ TokenStore = (*Redis)(nil)
+
+// Redis is a TokenStore backed by a Redis client. Each record is stored as
+// a JSON value under KeyPrefix+serial, with its TTL set to match the
+// record's expiry - so Redis expires stale records itself, and Sweep is a
+// no-op. A record whose expiry is not in the future when it is written is
+// stored without a TTL; Redis does not expire it and Sweep does not remove
+// it.
+type Redis struct {
+	Client      *redis.Client
+	KeyPrefix   string
+	SecretBytes uint
+	HashParams  HashParams
+}
+
+// NewRedis constructs a Redis store using client, issuing secretBytes-byte
+// secrets hashed with DefaultHashParams and keyed under the "splittoken:"
+// prefix.
+func NewRedis(client *redis.Client, secretBytes uint) *Redis {
+	return &Redis{Client: client, KeyPrefix: "splittoken:", SecretBytes: secretBytes, HashParams: DefaultHashParams}
+}
+
+// redisRecord is Record's on-the-wire JSON representation. Record itself
+// carries no struct tags; redisRecord has the same fields, so the two
+// convert directly, and adds the JSON names.
+type redisRecord struct {
+	Serial     uuid.UUID `json:"serial"`
+	Usage      string    `json:"usage"`
+	SecretHash string    `json:"secret_hash"`
+	IssuedAt   time.Time `json:"issued_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Metadata   []byte    `json:"metadata,omitempty"`
+}
+
+func (r *Redis) key(serial uuid.UUID) string {
+	return r.KeyPrefix + serial.String()
+}
+
+func (r *Redis) Issue(ctx context.Context, usage string, ttl time.Duration) (splittoken.Token, Record, error) {
+	tk, err := splittoken.Generate(usage, r.SecretBytes)
+	if err != nil {
+		return "", Record{}, err
+	}
+
+	salt := make([]byte, r.HashParams.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", Record{}, err
+	}
+
+	now := time.Now()
+	rec := Record{
+		Serial:     tk.Serial(),
+		Usage:      usage,
+		SecretHash: hashSecret(tk.Secret(), r.HashParams, salt),
+		IssuedAt:   now,
+		ExpiresAt:  now.Add(ttl),
+	}
+
+	bs, err := json.Marshal(redisRecord(rec))
+	if err != nil {
+		return "", Record{}, err
+	}
+	if err := r.Client.Set(ctx, r.key(rec.Serial), bs, ttl).Err(); err != nil {
+		return "", Record{}, err
+	}
+
+	return tk, rec, nil
+}
+
+func (r *Redis) Lookup(ctx context.Context, t splittoken.Token) (Record, error) {
+	if err := splittoken.Verify(t); err != nil {
+		return Record{}, err
+	}
+
+	rec, err := r.Get(ctx, t.Serial())
+	if err != nil {
+		return Record{}, err
+	}
+	if !compareSecret(t.Secret(), rec.SecretHash) {
+		return Record{}, ErrSecretMismatch
+	}
+	if time.Now().After(rec.ExpiresAt) {
+		return Record{}, ErrExpired
+	}
+	return rec, nil
+}
+
+func (r *Redis) Get(ctx context.Context, serial uuid.UUID) (Record, error) {
+	bs, err := r.Client.Get(ctx, r.key(serial)).Bytes()
+	if err == redis.Nil {
+		return Record{}, ErrNotFound
+	}
+	if err != nil {
+		return Record{}, err
+	}
+
+	var rec redisRecord
+	if err := json.Unmarshal(bs, &rec); err != nil {
+		return Record{}, err
+	}
+	return Record(rec), nil
+}
+
+func (r *Redis) Put(ctx context.Context, rec Record) error {
+	bs, err := json.Marshal(redisRecord(rec))
+	if err != nil {
+		return err
+	}
+	return r.Client.Set(ctx, r.key(rec.Serial), bs, time.Until(rec.ExpiresAt)).Err()
+}
+
+// CompareAndSwap uses Redis' WATCH/MULTI to make the read-compare-write
+// atomic: it watches the key, re-reads it inside the transaction, and
+// only issues the SET if its Metadata still matches prevMetadata, so a
+// concurrent writer that commits first aborts this one with
+// redis.TxFailedErr, reported as a failed swap, instead of letting both
+// succeed.
+func (r *Redis) CompareAndSwap(ctx context.Context, rec Record, prevMetadata []byte) (bool, error) {
+	key := r.key(rec.Serial)
+	swapped := false
+
+	err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		swapped = false
+		cur, err := tx.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var curRec redisRecord
+		if err := json.Unmarshal(cur, &curRec); err != nil {
+			return err
+		}
+		if !bytes.Equal(curRec.Metadata, prevMetadata) {
+			return nil
+		}
+
+		bs, err := json.Marshal(redisRecord(rec))
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, bs, time.Until(rec.ExpiresAt))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		swapped = true
+		return nil
+	}, key)
+	if err == redis.TxFailedErr {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+func (r *Redis) Revoke(ctx context.Context, serial uuid.UUID) error {
+	n, err := r.Client.Del(ctx, r.key(serial)).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Sweep is a no-op: Redis expires each record's key via its TTL, set to
+// match ExpiresAt when it was written. Records written with an ExpiresAt
+// not in the future have no TTL and are not removed.
+func (r *Redis) Sweep(ctx context.Context) (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,140 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/iterate/splittoken/store"
+)
+
+func newTestRedis(t *testing.T) *store.Redis {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis.Run() returned err %v", err)
+	}
+	t.Cleanup(mr.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return store.NewRedis(client, 24)
+}
+
+func TestRedisIssueAndLookup(t *testing.T) {
+	rs := newTestRedis(t)
+	ctx := context.Background()
+
+	tk, rec, err := rs.Issue(ctx, "test", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+	if rec.Usage != "test" {
+		t.Errorf("rec.Usage = %q; want %q", rec.Usage, "test")
+	}
+
+	got, err := rs.Lookup(ctx, tk)
+	if err != nil {
+		t.Fatalf("Lookup() returned err %v", err)
+	}
+	if got.Serial != rec.Serial {
+		t.Errorf("Lookup().Serial = %s; want %s", got.Serial, rec.Serial)
+	}
+}
+
+func TestRedisLookupExpired(t *testing.T) {
+	rs := newTestRedis(t)
+	ctx := context.Background()
+
+	tk, _, err := rs.Issue(ctx, "test", -time.Second)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	if _, err := rs.Lookup(ctx, tk); !errors.Is(err, store.ErrExpired) {
+		t.Errorf("Lookup() error = %v, want %v", err, store.ErrExpired)
+	}
+}
+
+func TestRedisLookupTamperedToken(t *testing.T) {
+	rs := newTestRedis(t)
+	ctx := context.Background()
+
+	tk, _, err := rs.Issue(ctx, "test", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	if _, err := rs.Lookup(ctx, tk+"garbage"); err == nil {
+		t.Errorf("Lookup() with a tampered token returned nil error")
+	}
+}
+
+func TestRedisRevoke(t *testing.T) {
+	rs := newTestRedis(t)
+	ctx := context.Background()
+
+	tk, rec, err := rs.Issue(ctx, "test", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	if err := rs.Revoke(ctx, rec.Serial); err != nil {
+		t.Fatalf("Revoke() returned err %v", err)
+	}
+	if _, err := rs.Lookup(ctx, tk); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Lookup() after revoke error = %v, want %v", err, store.ErrNotFound)
+	}
+	if err := rs.Revoke(ctx, rec.Serial); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("second Revoke() error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func TestRedisPutAndGet(t *testing.T) {
+	rs := newTestRedis(t)
+	ctx := context.Background()
+
+	_, rec, err := rs.Issue(ctx, "test", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	rec.Metadata = []byte(`{"rotated":true}`)
+	if err := rs.Put(ctx, rec); err != nil {
+		t.Fatalf("Put() returned err %v", err)
+	}
+
+	got, err := rs.Get(ctx, rec.Serial)
+	if err != nil {
+		t.Fatalf("Get() returned err %v", err)
+	}
+	if string(got.Metadata) != string(rec.Metadata) {
+		t.Errorf("Get().Metadata = %s; want %s", got.Metadata, rec.Metadata)
+	}
+
+	if _, err := rs.Get(ctx, uuid.New()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Get() on an unknown serial error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func TestRedisSweepNoop(t *testing.T) {
+	rs := newTestRedis(t)
+	ctx := context.Background()
+
+	if _, _, err := rs.Issue(ctx, "test", -time.Second); err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	n, err := rs.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep() returned err %v", err)
+	}
+	if n != 0 {
+		t.Errorf("Sweep() = %d; want 0 - Sweep is a no-op for Redis", n)
+	}
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/iterate/splittoken"
+)
+
+var _ TokenStore = (*SQL)(nil)
+
+// SQL is a TokenStore backed by a database/sql.DB. It expects a table
+// (Table, default "split_tokens") shaped like:
+//
+//	CREATE TABLE split_tokens (
+//		serial      TEXT PRIMARY KEY,
+//		usage       TEXT NOT NULL,
+//		secret_hash TEXT NOT NULL,
+//		issued_at   TIMESTAMP NOT NULL,
+//		expires_at  TIMESTAMP NOT NULL,
+//		metadata    BLOB
+//	)
+//
+// Queries use "?" placeholders; rebind them (e.g. with sqlx.Rebind) before
+// use against a driver that expects "$1"-style placeholders, such as
+// Postgres. Put's upsert uses SQLite/Postgres "ON CONFLICT" syntax;
+// rewrite it to "ON DUPLICATE KEY UPDATE" for MySQL.
+type SQL struct {
+	DB          *sql.DB
+	Table       string
+	SecretBytes uint
+	HashParams  HashParams
+}
+
+// NewSQL constructs a SQL store against db, issuing secretBytes-byte
+// secrets hashed with DefaultHashParams and stored in the "split_tokens"
+// table.
+func NewSQL(db *sql.DB, secretBytes uint) *SQL {
+	return &SQL{DB: db, Table: "split_tokens", SecretBytes: secretBytes, HashParams: DefaultHashParams}
+}
+
+func (s *SQL) table() string {
+	if s.Table == "" {
+		return "split_tokens"
+	}
+	return s.Table
+}
+
+func (s *SQL) Issue(ctx context.Context, usage string, ttl time.Duration) (splittoken.Token, Record, error) {
+	tk, err := splittoken.Generate(usage, s.SecretBytes)
+	if err != nil {
+		return "", Record{}, err
+	}
+
+	salt := make([]byte, s.HashParams.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", Record{}, err
+	}
+
+	now := time.Now()
+	rec := Record{
+		Serial:     tk.Serial(),
+		Usage:      usage,
+		SecretHash: hashSecret(tk.Secret(), s.HashParams, salt),
+		IssuedAt:   now,
+		ExpiresAt:  now.Add(ttl),
+	}
+
+	_, err = s.DB.ExecContext(ctx,
+		`INSERT INTO `+s.table()+` (serial, usage, secret_hash, issued_at, expires_at, metadata) VALUES (?, ?, ?, ?, ?, ?)`,
+		rec.Serial.String(), rec.Usage, rec.SecretHash, rec.IssuedAt, rec.ExpiresAt, rec.Metadata)
+	if err != nil {
+		return "", Record{}, err
+	}
+
+	return tk, rec, nil
+}
+
+func (s *SQL) Lookup(ctx context.Context, t splittoken.Token) (Record, error) {
+	if err := splittoken.Verify(t); err != nil {
+		return Record{}, err
+	}
+
+	rec, err := s.load(ctx, t.Serial())
+	if err != nil {
+		return Record{}, err
+	}
+	if !compareSecret(t.Secret(), rec.SecretHash) {
+		return Record{}, ErrSecretMismatch
+	}
+	if time.Now().After(rec.ExpiresAt) {
+		return Record{}, ErrExpired
+	}
+	return rec, nil
+}
+
+func (s *SQL) load(ctx context.Context, serial uuid.UUID) (Record, error) {
+	row := s.DB.QueryRowContext(ctx,
+		`SELECT serial, usage, secret_hash, issued_at, expires_at, metadata FROM `+s.table()+` WHERE serial = ?`,
+		serial.String())
+
+	var rec Record
+	var serialStr string
+	if err := row.Scan(&serialStr, &rec.Usage, &rec.SecretHash, &rec.IssuedAt, &rec.ExpiresAt, &rec.Metadata); err != nil {
+		if err == sql.ErrNoRows {
+			return Record{}, ErrNotFound
+		}
+		return Record{}, err
+	}
+	id, err := uuid.Parse(serialStr)
+	if err != nil {
+		return Record{}, err
+	}
+	rec.Serial = id
+	return rec, nil
+}
+
+func (s *SQL) Get(ctx context.Context, serial uuid.UUID) (Record, error) {
+	return s.load(ctx, serial)
+}
+
+func (s *SQL) Put(ctx context.Context, rec Record) error {
+	_, err := s.DB.ExecContext(ctx,
+		`INSERT INTO `+s.table()+` (serial, usage, secret_hash, issued_at, expires_at, metadata) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (serial) DO UPDATE SET usage = excluded.usage, secret_hash = excluded.secret_hash,
+				issued_at = excluded.issued_at, expires_at = excluded.expires_at, metadata = excluded.metadata`,
+		rec.Serial.String(), rec.Usage, rec.SecretHash, rec.IssuedAt, rec.ExpiresAt, rec.Metadata)
+	return err
+}
+
+func (s *SQL) CompareAndSwap(ctx context.Context, rec Record, prevMetadata []byte) (bool, error) {
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE `+s.table()+` SET usage = ?, secret_hash = ?, issued_at = ?, expires_at = ?, metadata = ?
+			WHERE serial = ? AND metadata = ?`,
+		rec.Usage, rec.SecretHash, rec.IssuedAt, rec.ExpiresAt, rec.Metadata, rec.Serial.String(), prevMetadata)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (s *SQL) Revoke(ctx context.Context, serial uuid.UUID) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM `+s.table()+` WHERE serial = ?`, serial.String())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQL) Sweep(ctx context.Context) (int, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM `+s.table()+` WHERE expires_at < ?`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
@@ -0,0 +1,170 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/iterate/splittoken/store"
+)
+
+func newMockSQL(t *testing.T) (*store.SQL, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() returned err %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return store.NewSQL(db, 24), mock
+}
+
+func TestSQLIssueAndLookup(t *testing.T) {
+	s, mock := newMockSQL(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`INSERT INTO split_tokens`).
+		WithArgs(sqlmock.AnyArg(), "test", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	tk, rec, err := s.Issue(ctx, "test", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"serial", "usage", "secret_hash", "issued_at", "expires_at", "metadata"}).
+		AddRow(rec.Serial.String(), rec.Usage, rec.SecretHash, rec.IssuedAt, rec.ExpiresAt, rec.Metadata)
+	mock.ExpectQuery(`SELECT serial, usage, secret_hash, issued_at, expires_at, metadata FROM split_tokens WHERE serial = \?`).
+		WithArgs(rec.Serial.String()).
+		WillReturnRows(rows)
+
+	got, err := s.Lookup(ctx, tk)
+	if err != nil {
+		t.Fatalf("Lookup() returned err %v", err)
+	}
+	if got.Serial != rec.Serial {
+		t.Errorf("Lookup().Serial = %s; want %s", got.Serial, rec.Serial)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestSQLLookupQueryError(t *testing.T) {
+	s, mock := newMockSQL(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`INSERT INTO split_tokens`).
+		WithArgs(sqlmock.AnyArg(), "test", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	tk, rec, err := s.Issue(ctx, "test", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	mock.ExpectQuery(`SELECT serial, usage, secret_hash, issued_at, expires_at, metadata FROM split_tokens WHERE serial = \?`).
+		WithArgs(rec.Serial.String()).
+		WillReturnError(sqlmock.ErrCancelled)
+
+	if _, err := s.Lookup(ctx, tk); err == nil {
+		t.Errorf("Lookup() with a query error returned nil error")
+	}
+}
+
+func TestSQLGet(t *testing.T) {
+	s, mock := newMockSQL(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`INSERT INTO split_tokens`).
+		WithArgs(sqlmock.AnyArg(), "test", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	_, rec, err := s.Issue(ctx, "test", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	rows := sqlmock.NewRows([]string{"serial", "usage", "secret_hash", "issued_at", "expires_at", "metadata"}).
+		AddRow(rec.Serial.String(), rec.Usage, rec.SecretHash, rec.IssuedAt, rec.ExpiresAt, rec.Metadata)
+	mock.ExpectQuery(`SELECT serial, usage, secret_hash, issued_at, expires_at, metadata FROM split_tokens WHERE serial = \?`).
+		WithArgs(rec.Serial.String()).
+		WillReturnRows(rows)
+
+	got, err := s.Get(ctx, rec.Serial)
+	if err != nil {
+		t.Fatalf("Get() returned err %v", err)
+	}
+	if got.Serial != rec.Serial {
+		t.Errorf("Get().Serial = %s; want %s", got.Serial, rec.Serial)
+	}
+}
+
+func TestSQLPutUpsert(t *testing.T) {
+	s, mock := newMockSQL(t)
+	ctx := context.Background()
+
+	rec := store.Record{
+		Usage:      "test",
+		SecretHash: "$argon2id$...",
+		IssuedAt:   time.Now(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+		Metadata:   []byte(`{"rotated":true}`),
+	}
+
+	mock.ExpectExec(`INSERT INTO split_tokens .* ON CONFLICT \(serial\) DO UPDATE SET`).
+		WithArgs(rec.Serial.String(), rec.Usage, rec.SecretHash, rec.IssuedAt, rec.ExpiresAt, rec.Metadata).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := s.Put(ctx, rec); err != nil {
+		t.Fatalf("Put() returned err %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestSQLRevoke(t *testing.T) {
+	s, mock := newMockSQL(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`INSERT INTO split_tokens`).
+		WithArgs(sqlmock.AnyArg(), "test", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	_, rec, err := s.Issue(ctx, "test", time.Hour)
+	if err != nil {
+		t.Fatalf("Issue() returned err %v", err)
+	}
+
+	mock.ExpectExec(`DELETE FROM split_tokens WHERE serial = \?`).
+		WithArgs(rec.Serial.String()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := s.Revoke(ctx, rec.Serial); err != nil {
+		t.Fatalf("Revoke() returned err %v", err)
+	}
+
+	mock.ExpectExec(`DELETE FROM split_tokens WHERE serial = \?`).
+		WithArgs(rec.Serial.String()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := s.Revoke(ctx, rec.Serial); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Revoke() on a missing serial error = %v, want %v", err, store.ErrNotFound)
+	}
+}
+
+func TestSQLSweep(t *testing.T) {
+	s, mock := newMockSQL(t)
+	ctx := context.Background()
+
+	mock.ExpectExec(`DELETE FROM split_tokens WHERE expires_at < \?`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := s.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep() returned err %v", err)
+	}
+	if n != 3 {
+		t.Errorf("Sweep() = %d; want 3", n)
+	}
+}
@@ -0,0 +1,158 @@
+// Package store persists split tokens the way the split-token pattern
+// intends: only the argon2id hash of a token's secret ever touches
+// storage, so database access alone is never enough to reconstruct or
+// forge a valid token.
+//
+// TokenStore is the interface backends implement; Memory, SQL and Redis
+// provide in-memory, database/sql and Redis-backed implementations
+// respectively. Middleware wires a TokenStore into an net/http stack.
+package store
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
+
+	"github.com/iterate/splittoken"
+)
+
+var (
+	// ErrNotFound is returned by Lookup when no record exists for a
+	// token's serial, and by Revoke when no record exists for serial.
+	ErrNotFound = errors.New("splittoken/store: record not found")
+	// ErrExpired is returned by Lookup when a record exists but its
+	// ExpiresAt has passed.
+	ErrExpired = errors.New("splittoken/store: token expired")
+	// ErrSecretMismatch is returned by Lookup when a record exists but
+	// the presented token's secret does not hash to the stored value.
+	ErrSecretMismatch = errors.New("splittoken/store: secret does not match")
+)
+
+// Record is what a TokenStore persists for an issued token: everything
+// needed to validate a later presentation of it without ever storing the
+// secret itself.
+type Record struct {
+	Serial     uuid.UUID
+	Usage      string
+	SecretHash string
+	IssuedAt   time.Time
+	ExpiresAt  time.Time
+	Metadata   []byte
+}
+
+// TokenStore issues and validates split tokens backed by persistent
+// storage, hashing the secret before it is ever written down.
+type TokenStore interface {
+	// Issue mints a new token for usage, persists its Record with the
+	// given ttl, and returns both.
+	Issue(ctx context.Context, usage string, ttl time.Duration) (splittoken.Token, Record, error)
+	// Lookup parses t, loads its Record by serial, and verifies the
+	// presented secret against the stored hash in constant time. It
+	// returns ErrNotFound, ErrExpired or ErrSecretMismatch as
+	// appropriate.
+	Lookup(ctx context.Context, t splittoken.Token) (Record, error)
+	// Put inserts or replaces rec directly, keyed by rec.Serial. It's
+	// for callers - such as Rotate - that mint a Record themselves,
+	// rather than through Issue.
+	Put(ctx context.Context, rec Record) error
+	// Get loads the record for serial directly, without verifying a
+	// token's secret against it. It's for callers - such as Rotate -
+	// that need to walk a chain of linked records by serial alone,
+	// without having the token that would let them use Lookup.
+	Get(ctx context.Context, serial uuid.UUID) (Record, error)
+	// CompareAndSwap replaces the record for rec.Serial with rec, but
+	// only if the record currently stored there still has Metadata
+	// byte-for-byte equal to prevMetadata; otherwise it leaves the store
+	// untouched. It reports whether the swap happened. Rotate uses this
+	// to detect two concurrent rotations racing on the same refresh
+	// token: whichever call's prevMetadata no longer matches lost the
+	// race and must not overwrite the winner's record.
+	CompareAndSwap(ctx context.Context, rec Record, prevMetadata []byte) (bool, error)
+	// Revoke deletes the record for serial, so that any token sharing
+	// it immediately fails Lookup with ErrNotFound.
+	Revoke(ctx context.Context, serial uuid.UUID) error
+	// Sweep deletes all records whose ExpiresAt has passed and returns
+	// how many were removed. Backends whose storage expires records on
+	// its own (such as Redis, via key TTLs) may implement this as a
+	// no-op that always returns 0.
+	Sweep(ctx context.Context) (int, error)
+}
+
+// HashParams configures the argon2id hashing of a token's secret before
+// storage. The zero value is not valid; use DefaultHashParams.
+type HashParams struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+// DefaultHashParams are the argon2id parameters used by Issue unless a
+// backend is constructed with its own HashParams; they follow the OWASP
+// baseline recommendation of 19 MiB memory, one iteration, and one degree
+// of parallelism.
+var DefaultHashParams = HashParams{
+	Time:    1,
+	Memory:  19 * 1024,
+	Threads: 1,
+	KeyLen:  32,
+	SaltLen: 16,
+}
+
+// hashSecret derives an argon2id hash of secret and encodes it, along with
+// the salt and parameters used, into a single self-describing string -
+// the same scheme used by the reference argon2 CLI - so that a later
+// compareSecret does not need the original HashParams to verify it.
+func hashSecret(secret []byte, params HashParams, salt []byte) string {
+	hash := argon2.IDKey(secret, salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Time, params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash))
+}
+
+// compareSecret reports whether secret hashes to encoded, a string
+// produced by hashSecret, comparing the derived hash in constant time.
+func compareSecret(secret []byte, encoded string) bool {
+	// $argon2id$v=19$m=19456,t=1,p=1$<salt>$<hash>
+	fields := strings.Split(encoded, "$")
+	if len(fields) != 6 || fields[1] != "argon2id" {
+		return false
+	}
+	params := strings.Split(strings.TrimPrefix(fields[3], "m="), ",")
+	if len(params) != 3 {
+		return false
+	}
+	memory, err := strconv.ParseUint(params[0], 10, 32)
+	if err != nil {
+		return false
+	}
+	iterations, err := strconv.ParseUint(strings.TrimPrefix(params[1], "t="), 10, 32)
+	if err != nil {
+		return false
+	}
+	threads, err := strconv.ParseUint(strings.TrimPrefix(params[2], "p="), 10, 8)
+	if err != nil {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(fields[4])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(fields[5])
+	if err != nil {
+		return false
+	}
+
+	got := argon2.IDKey(secret, salt, uint32(iterations), uint32(memory), uint8(threads), uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1
+}
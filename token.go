@@ -13,15 +13,21 @@
 //
 // This Paragon.ie post from 2017 describes this approach:
 // https://paragonie.com/blog/2017/02/split-tokens-token-based-authentication-protocols-without-side-channels.
+//
+// The wire format is versioned: the first byte of the base62-decoded payload
+// selects a Codec registered with RegisterVersion, which governs how the
+// serial is sized and how the trailing checksum/MAC is computed. The one
+// exception is v1, the original CRC32/UUID layout that New and Verify
+// always use: it predates versioning and carries no leading version byte,
+// so every token issued before this package gained version tags keeps
+// parsing. See RegisterVersion for how to add or use other versions.
 package splittoken
 
 import (
 	"crypto/rand"
 	"crypto/subtle"
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"hash/crc32"
 	"strings"
 
 	"github.com/eknkc/basex"
@@ -35,6 +41,7 @@ var enc = mustEnc(basex.NewEncoding(stdEnc))
 var (
 	ErrInvalidChecksum = errors.New("invalid checksum")
 	ErrInvalidSyntax   = errors.New("invalid syntax")
+	ErrUnknownVersion  = errors.New("unknown token version")
 )
 
 // Token is a split token.
@@ -48,13 +55,32 @@ func (t Token) Secret() []byte {
 	return p.secret
 }
 
-func (t Token) Serial() uuid.UUID {
+// SerialBytes returns the raw serial encoded in the token, in whatever form
+// its version's Codec uses: a 16-byte UUID for v1, or an arbitrary-length
+// identifier for codecs - such as v2 - that support variable-length
+// serials.
+func (t Token) SerialBytes() []byte {
 	p, err := parse(t)
 	if err != nil {
-		return uuid.Nil
+		return nil
 	}
 	return p.serial
 }
+
+// Serial returns the token's serial as a uuid.UUID. It is a compatibility
+// shim for v1 tokens, whose serial is always a 16-byte UUID. Tokens encoded
+// under a version with variable-length serials return uuid.Nil here; use
+// SerialBytes instead.
+func (t Token) Serial() uuid.UUID {
+	p, err := parse(t)
+	if err != nil || len(p.serial) != 16 {
+		return uuid.Nil
+	}
+	var id uuid.UUID
+	copy(id[:], p.serial)
+	return id
+}
+
 func (t Token) Usage() string {
 	p, err := parse(t)
 	if err != nil {
@@ -63,47 +89,148 @@ func (t Token) Usage() string {
 	return p.usage
 }
 
+// Version returns the wire-format version the token was encoded under, or 0
+// if the token cannot be parsed.
+func (t Token) Version() uint8 {
+	p, _ := parse(t)
+	return p.version
+}
+
+// parts is the decoded form of a token, plus the tag embedded in it, prior
+// to MAC verification.
+type parts struct {
+	version uint8
+	usage   string
+	serial  []byte
+	secret  []byte
+	tag     []byte
+}
+
 func parse(t Token) (parts, error) {
 	var res parts
-	ps := strings.Split(string(t), "_")
-	if len(ps) != 2 {
+	usage, rest, ok := cutUsage(string(t))
+	if !ok {
 		return parts{}, ErrInvalidSyntax
 	}
-	res.usage = ps[0]
+	res.usage = usage
 
-	bs, err := enc.Decode(ps[1])
-	if err != nil {
+	bs, err := enc.Decode(rest)
+	if err != nil || len(bs) < 1 {
 		return res, ErrInvalidSyntax
 	}
 
-	// the token must be at least 16 + 1 + 4 = 21 bytes
-	if len(bs) < 21 {
-		return res, ErrInvalidSyntax
+	// A v1 token has no version byte, so its first serial byte can look
+	// like a registered version tag. A payload whose v1 checksum verifies
+	// is therefore taken as v1 before any version tag is tried.
+	if serial, secret, tag, ok := tryLegacyV1(bs); ok {
+		v1 := parts{version: versionCRC32, usage: usage, serial: serial, secret: secret, tag: tag}
+		if verifyTag(v1, nil) == nil {
+			return v1, nil
+		}
+	}
+
+	if version, serial, secret, tag, ok := tryVersioned(bs); ok {
+		res.version = version
+		res.serial = serial
+		res.secret = secret
+		res.tag = tag
+		return res, nil
+	}
+
+	// Tokens issued before the versioned wire format existed carry no
+	// leading version byte at all - just serial(16) || secret ||
+	// crc32(4), exactly what New produced before RegisterVersion
+	// existed. Falling back to that legacy layout, rather than
+	// rejecting byte 0 as an unrecognized version tag, keeps every
+	// token issued before this upgrade shipped parseable.
+	if serial, secret, tag, ok := tryLegacyV1(bs); ok {
+		res.version = versionCRC32
+		res.serial = serial
+		res.secret = secret
+		res.tag = tag
+		return res, nil
 	}
 
-	secretLen := len(bs) - 16 - 4
-	res.secret = make([]byte, secretLen, secretLen)
+	return res, ErrInvalidSyntax
+}
+
+// tryVersioned parses bs as a version-tagged token: a one-byte version tag
+// followed by whatever body layout that version's Codec describes. v1
+// never appears here - it predates the version tag and is only reached via
+// tryLegacyV1 - so a leading byte of versionCRC32 is treated as a
+// non-match, same as an unregistered version would be.
+func tryVersioned(bs []byte) (version uint8, serial, secret, tag []byte, ok bool) {
+	version = bs[0]
+	if version == versionCRC32 {
+		return 0, nil, nil, nil, false
+	}
+	codec, found := getVersion(version)
+	if !found {
+		return 0, nil, nil, nil, false
+	}
+	body := bs[1:]
+	tagSize := codec.TagSize()
 
-	copy(res.serial[:], bs[0:16])
-	copy(res.secret, bs[16:16+secretLen])
+	if n := codec.SerialSize(); n > 0 {
+		if len(body) < n+tagSize {
+			return 0, nil, nil, nil, false
+		}
+		serial = body[:n]
+		secret = body[n : len(body)-tagSize]
+	} else {
+		if len(body) < 1 {
+			return 0, nil, nil, nil, false
+		}
+		sl := int(body[0])
+		if len(body) < 1+sl+tagSize {
+			return 0, nil, nil, nil, false
+		}
+		serial = body[1 : 1+sl]
+		secret = body[1+sl : len(body)-tagSize]
+	}
+	if len(secret) < codec.MinSecretLen() {
+		return 0, nil, nil, nil, false
+	}
+	tag = body[len(body)-tagSize:]
+	return version, serial, secret, tag, true
+}
 
-	checksum := bs[len(bs)-4:]
-	wantSum := make([]byte, 4, 4)
-	binary.BigEndian.PutUint32(wantSum, crc32.ChecksumIEEE(bs[:len(bs)-4]))
-	if subtle.ConstantTimeCompare(checksum, wantSum) != 1 {
-		return res, ErrInvalidChecksum
+// tryLegacyV1 parses bs as the pre-versioning wire format: a bare
+// serial(16) || secret || crc32(4), with no leading version byte. It only
+// checks shape, not the checksum itself - an invalid checksum is still a
+// legacy v1 token, just one Verify should reject - so the only thing that
+// makes this "fail" is bs being too short to hold a 16-byte serial, a
+// secret, and a 4-byte tag.
+func tryLegacyV1(bs []byte) (serial, secret, tag []byte, ok bool) {
+	const serialSize = 16
+	const tagSize = 4
+	if len(bs) < serialSize+1+tagSize {
+		return nil, nil, nil, false
 	}
+	secretLen := len(bs) - serialSize - tagSize
+	return bs[:serialSize], bs[serialSize : serialSize+secretLen], bs[serialSize+secretLen:], true
+}
 
-	return res, nil
+// verifyTag recomputes p's tag using its version's Codec and compares it in
+// constant time against the tag embedded in the token. key is passed
+// through to the codec; codecs that don't need one, such as v1's CRC32,
+// ignore it.
+func verifyTag(p parts, key []byte) error {
+	codec, ok := getVersion(p.version)
+	if !ok {
+		return ErrUnknownVersion
+	}
+	want := codec.Sign(p.usage, p.serial, p.secret, key)
+	if subtle.ConstantTimeCompare(want, p.tag) != 1 {
+		return ErrInvalidChecksum
+	}
+	return nil
 }
 
-// NewToken constructs a new token.
+// New constructs a new v1 token: a 16-byte UUID serial and a CRC32
+// checksum.
 func New(usage string, serial uuid.UUID, secret []byte) (Token, error) {
-	return encode(parts{
-		usage:  usage,
-		serial: serial,
-		secret: secret,
-	})
+	return encodeVersioned(versionCRC32, usage, serial[:], secret, nil)
 }
 
 func Generate(usage string, bytes uint) (Token, error) {
@@ -115,29 +242,74 @@ func Generate(usage string, bytes uint) (Token, error) {
 	return New(usage, id, secret)
 }
 
-func encode(p parts) (Token, error) {
-	if len(p.usage) < 1 {
-		return "", ErrInvalidSyntax
+// NewVersioned constructs a token under an explicitly chosen wire-format
+// version, using whichever Codec was registered for it via RegisterVersion.
+// Most callers want New (v1) or NewAuthenticated; NewVersioned exists for
+// codecs - like v2's HMAC format - that need a caller-supplied key, and for
+// future versions with serial types New can't express, such as ULIDs,
+// snowflake IDs, or other raw byte identifiers.
+func NewVersioned(version uint8, usage string, serial, secret, key []byte) (Token, error) {
+	return encodeVersioned(version, usage, serial, secret, key)
+}
+
+// cutUsage splits a token string into its usage and base62 payload. It
+// cuts on the last "_" rather than the first, since the base62 alphabet
+// never produces one - so usage itself may contain underscores, such as
+// the "_at"/"_rt" suffixes Pair tokens are tagged with.
+func cutUsage(t string) (usage, payload string, ok bool) {
+	i := strings.LastIndex(t, "_")
+	if i < 0 {
+		return "", "", false
 	}
-	if strings.Contains(p.usage, "_") {
+	return t[:i], t[i+1:], true
+}
+
+func encodeVersioned(version uint8, usage string, serial, secret, key []byte) (Token, error) {
+	if len(usage) < 1 {
 		return "", ErrInvalidSyntax
 	}
-	if len(p.secret) < 1 {
+	codec, ok := getVersion(version)
+	if !ok {
+		return "", ErrUnknownVersion
+	}
+	if len(secret) < codec.MinSecretLen() {
 		return "", ErrInvalidSyntax
 	}
-	bl := len(p.serial) + len(p.secret) + 4
-	bs := make([]byte, bl, bl)
-	copy(bs[:16], p.serial[:])
-	copy(bs[16:], p.secret)
 
-	binary.BigEndian.PutUint32(bs[len(bs)-4:], crc32.ChecksumIEEE(bs[:len(bs)-4]))
-	return Token(fmt.Sprintf("%s_%s", p.usage, enc.Encode(bs))), nil
-}
+	var body []byte
+	if n := codec.SerialSize(); n > 0 {
+		if len(serial) != n {
+			return "", ErrInvalidSyntax
+		}
+		body = append(body, serial...)
+	} else {
+		if len(serial) > 255 {
+			return "", ErrInvalidSyntax
+		}
+		body = append(body, byte(len(serial)))
+		body = append(body, serial...)
+	}
+	body = append(body, secret...)
 
-type parts struct {
-	usage  string
-	serial uuid.UUID
-	secret []byte
+	tag := codec.Sign(usage, serial, secret, key)
+	if len(tag) != codec.TagSize() {
+		return "", fmt.Errorf("splittoken: codec for version %d produced a %d-byte tag, want %d", version, len(tag), codec.TagSize())
+	}
+	body = append(body, tag...)
+
+	// v1 predates the versioned wire format and has no leading version
+	// byte - serial(16) || secret || tag(4), exactly as New produced
+	// before RegisterVersion existed - so tokens issued before this
+	// upgrade keep parsing; see parse's tryLegacyV1 fallback.
+	if version == versionCRC32 {
+		return Token(usage + "_" + enc.Encode(body)), nil
+	}
+
+	bs := make([]byte, 0, 1+len(body))
+	bs = append(bs, version)
+	bs = append(bs, body...)
+
+	return Token(usage + "_" + enc.Encode(bs)), nil
 }
 
 func mustEnc(encoding *basex.Encoding, err error) *basex.Encoding {
@@ -147,9 +319,35 @@ func mustEnc(encoding *basex.Encoding, err error) *basex.Encoding {
 	return encoding
 }
 
+// Verify checks t's v1 CRC32 checksum. It does not protect against a client
+// forging a token; it only catches transcription errors and garbage input
+// before it reaches the database. Use VerifyAuthenticated or
+// VerifyVersioned with a MAC-based version for tokens that must resist
+// forgery on their own.
+//
+// Verify rejects any token that didn't parse as v1 with ErrUnknownVersion,
+// rather than checking it against v1's unkeyed CRC32 with a nil key: a
+// version-2+ token is MAC-protected and must be checked with
+// VerifyVersioned or VerifyAuthenticated and the right key, or it isn't
+// being checked against anything at all.
 func Verify(t Token) error {
-	if _, err := parse(t); err != nil {
+	p, err := parse(t)
+	if err != nil {
 		return err
 	}
-	return nil
+	if p.version != versionCRC32 {
+		return ErrUnknownVersion
+	}
+	return verifyTag(p, nil)
+}
+
+// VerifyVersioned checks t's checksum/MAC against the Codec registered for
+// its version, using key - ignored by codecs, such as v1, that don't need
+// one.
+func VerifyVersioned(t Token, key []byte) error {
+	p, err := parse(t)
+	if err != nil {
+		return err
+	}
+	return verifyTag(p, key)
 }
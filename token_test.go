@@ -12,15 +12,15 @@ import (
 	"github.com/iterate/splittoken"
 )
 
-func TestNewToken(t *testing.T) {
+func TestNew(t *testing.T) {
 	id := uuid.New()
 	bs := make([]byte, 24)
 	if _, err := rand.Read(bs); err != nil {
 		t.Fatalf("reading bytes: %v", err)
 	}
-	tk, err := splittoken.NewToken("test", id, bs)
+	tk, err := splittoken.New("test", id, bs)
 	if err != nil {
-		t.Fatalf("NewToken() returned err %v", err)
+		t.Fatalf("New() returned err %v", err)
 	}
 	if s := tk.Serial(); s != id {
 		t.Errorf("tk.Serial() = %s; want %s", s, id)
@@ -35,14 +35,14 @@ func TestNewToken(t *testing.T) {
 }
 
 
-func ExampleNewToken() {
+func ExampleNew() {
 	usage := "myu"
 	id, err := uuid.Parse("123c3af9-6eac-4392-b673-481cfe3c6d6d")
 	if err != nil {
 		log.Fatal(err)
 	}
 	secret := []byte("autogenerated secret")
-	tk, err := splittoken.NewToken(usage, id, secret)
+	tk, err := splittoken.New(usage, id, secret)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,121 @@
+package splittoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"sync"
+)
+
+// versionCRC32 is the original wire-format version: a fixed 16-byte UUID
+// serial and a CRC32 checksum that is not keyed, so it only guards against
+// transcription errors and garbage input, not forgery.
+const versionCRC32 uint8 = 1
+
+// versionHMAC is the HMAC-authenticated wire-format version: a
+// variable-length serial and a truncated HMAC-SHA256 tag keyed on a
+// caller-supplied key, so a service holding the key can reject a forged
+// token without a database round-trip.
+const versionHMAC uint8 = 2
+
+// Codec describes a single wire-format version: how its serial is sized,
+// how long its trailing checksum/MAC is, and how that checksum/MAC is
+// computed. Register one with RegisterVersion to make it usable by
+// NewVersioned, VerifyVersioned and parse.
+type Codec interface {
+	// SerialSize returns the fixed length, in bytes, of a serial encoded
+	// under this codec, or 0 if the codec allows variable-length
+	// serials. A variable-length serial is length-prefixed on the wire
+	// by a single byte, so it is limited to 255 bytes.
+	SerialSize() int
+	// MinSecretLen returns the minimum number of secret bytes this
+	// codec will accept.
+	MinSecretLen() int
+	// TagSize returns the length, in bytes, of the trailing
+	// checksum/MAC that Sign produces.
+	TagSize() int
+	// Sign computes the trailing checksum/MAC over usage, serial and
+	// secret. key is only meaningful to MAC-based codecs; CRC-based
+	// codecs may ignore it.
+	Sign(usage string, serial, secret, key []byte) []byte
+}
+
+var (
+	versionsMu sync.RWMutex
+	versions   = map[uint8]Codec{}
+)
+
+// RegisterVersion registers codec as the implementation of wire-format
+// version v, so that NewVersioned(v, ...), VerifyVersioned and Token
+// parsing recognize it. It panics if v is already registered; register
+// each version exactly once, typically from an init function.
+func RegisterVersion(v uint8, codec Codec) {
+	versionsMu.Lock()
+	defer versionsMu.Unlock()
+	if _, ok := versions[v]; ok {
+		panic(fmt.Sprintf("splittoken: version %d already registered", v))
+	}
+	versions[v] = codec
+}
+
+func getVersion(v uint8) (Codec, bool) {
+	versionsMu.RLock()
+	defer versionsMu.RUnlock()
+	c, ok := versions[v]
+	return c, ok
+}
+
+// Versions returns the currently registered wire-format versions, in
+// ascending order.
+func Versions() []uint8 {
+	versionsMu.RLock()
+	defer versionsMu.RUnlock()
+	vs := make([]uint8, 0, len(versions))
+	for v := range versions {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i] < vs[j] })
+	return vs
+}
+
+func init() {
+	RegisterVersion(versionCRC32, crc32Codec{})
+	RegisterVersion(versionHMAC, hmacCodec{})
+}
+
+// crc32Codec implements the original v1 layout: a 16-byte UUID serial and
+// an unkeyed CRC32 checksum over serial||secret.
+type crc32Codec struct{}
+
+func (crc32Codec) SerialSize() int   { return 16 }
+func (crc32Codec) MinSecretLen() int { return 1 }
+func (crc32Codec) TagSize() int      { return 4 }
+
+func (crc32Codec) Sign(usage string, serial, secret, _ []byte) []byte {
+	bs := make([]byte, 0, len(serial)+len(secret))
+	bs = append(bs, serial...)
+	bs = append(bs, secret...)
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc32.ChecksumIEEE(bs))
+	return sum
+}
+
+// hmacCodec implements v2: a variable-length serial and a truncated
+// HMAC-SHA256 tag over usage||serial||secret, keyed on a caller-supplied
+// key.
+type hmacCodec struct{}
+
+func (hmacCodec) SerialSize() int   { return 0 }
+func (hmacCodec) MinSecretLen() int { return 1 }
+func (hmacCodec) TagSize() int      { return macSize }
+
+func (hmacCodec) Sign(usage string, serial, secret, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(usage))
+	mac.Write(serial)
+	mac.Write(secret)
+	return mac.Sum(nil)[:macSize]
+}
@@ -0,0 +1,36 @@
+//go:build go1.18
+
+package splittoken_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/iterate/splittoken"
+)
+
+// FuzzVersions round-trips every registered wire-format version through
+// NewVersioned/VerifyVersioned, so a new Codec automatically gets the same
+// coverage as v1 and v2 without the fuzz test needing to know about it.
+func FuzzVersions(f *testing.F) {
+	f.Add([]byte("0123456789abcdef"), []byte("a secret"), []byte("a key"))
+	f.Add([]byte("ulid-or-snowflake-id"), []byte("a secret"), []byte("a key"))
+
+	f.Fuzz(func(t *testing.T, serial, secret, key []byte) {
+		for _, version := range splittoken.Versions() {
+			tk, err := splittoken.NewVersioned(version, "fz", serial, secret, key)
+			if err != nil {
+				continue
+			}
+			if got := tk.SerialBytes(); !bytes.Equal(got, serial) {
+				t.Errorf("version %d: SerialBytes() = %x; want %x", version, got, serial)
+			}
+			if got := tk.Secret(); !bytes.Equal(got, secret) {
+				t.Errorf("version %d: Secret() = %x; want %x", version, got, secret)
+			}
+			if err := splittoken.VerifyVersioned(tk, key); err != nil {
+				t.Errorf("version %d: VerifyVersioned() = %v; want nil", version, err)
+			}
+		}
+	})
+}